@@ -2,20 +2,32 @@ package exec
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"os/exec"
-	"strings"
+	"io"
 	"time"
 
+	"github.com/google/shlex"
 	"github.com/sethvargo/go-retry"
 )
 
 const (
-	commandSeparator = " "
-
 	defaultCommandTimeout = 10 * time.Second
 	defaultCommandWait    = 3 * time.Second
+
+	defaultInitialBackoff = 200 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+	defaultMultiplier     = 2.0
+)
+
+// Backoff selects the retry schedule used by CommandConfig.MaxRetries.
+type Backoff int
+
+const (
+	// Constant retries after a fixed CommandConfig.Wait between attempts.
+	Constant Backoff = iota
+	// Exponential retries with a base of InitialBackoff, doubled (by
+	// Multiplier) on each attempt up to MaxBackoff, with jitter applied.
+	Exponential
 )
 
 func toPtr(d time.Duration) *time.Duration {
@@ -53,24 +65,50 @@ func (r *Result) String() string {
 	return str
 }
 
-func fromExitError(err *exec.ExitError) *Result {
-	return &Result{
-		Stderr:   string(err.Stderr),
-		ExitCode: err.ExitCode(),
-	}
-}
-
 type CommandConfig struct {
 	Timeout    *time.Duration
 	Wait       *time.Duration
 	MaxRetries int
+
+	// Backoff selects the retry schedule. Defaults to Constant, which
+	// preserves the original fixed-Wait behavior.
+	Backoff Backoff
+	// InitialBackoff, MaxBackoff, and Multiplier only apply when Backoff is
+	// Exponential.
+	InitialBackoff *time.Duration
+	MaxBackoff     *time.Duration
+	Multiplier     float64
+
+	// ShouldRetry overrides the default retry predicate (retry on any
+	// non-zero exit code) so callers can, e.g., only retry specific exit
+	// codes or stderr patterns mirroring cse_helpers.sh.
+	ShouldRetry func(*Result, error) bool
+
+	// Stdin, if set, is piped to the subprocess.
+	Stdin io.Reader
+	// Env, if set, replaces the subprocess environment (same semantics as
+	// exec.Cmd.Env, i.e. nil inherits the current process's environment).
+	// For remote Executors (KubectlExecExecutor, SSHExecutor) this describes
+	// the remote command's environment, not the local client process's.
+	Env []string
+	// Dir, if set, is the subprocess's working directory. For remote
+	// Executors (KubectlExecExecutor, SSHExecutor) this is the remote
+	// command's working directory, not the local client process's.
+	Dir string
+
+	// Stdout and Stderr, if set, receive the subprocess's output as it's
+	// produced (e.g. t.Log or Ginkgo's writer), instead of it only
+	// appearing once the command has exited. Result always carries the full
+	// captured output regardless of whether these are set.
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
 func (cc *CommandConfig) validate() {
 	if cc == nil {
 		return
 	}
-	if cc.Timeout != nil {
+	if cc.Timeout == nil {
 		cc.Timeout = toPtr(defaultCommandTimeout)
 	}
 	if cc.Wait == nil {
@@ -79,111 +117,127 @@ func (cc *CommandConfig) validate() {
 	if cc.MaxRetries < 0 {
 		cc.MaxRetries = 0
 	}
+	if cc.InitialBackoff == nil {
+		cc.InitialBackoff = toPtr(defaultInitialBackoff)
+	}
+	if cc.MaxBackoff == nil {
+		cc.MaxBackoff = toPtr(defaultMaxBackoff)
+	}
+	if cc.Multiplier <= 0 {
+		cc.Multiplier = defaultMultiplier
+	}
+	if cc.ShouldRetry == nil {
+		cc.ShouldRetry = func(res *Result, err error) bool {
+			return err != nil || res.Failed()
+		}
+	}
 }
 
+// newBackoff builds the retry schedule described by cc. WithMaxDuration caps
+// total elapsed time across all attempts so a high MaxRetries count can't
+// keep a caller retrying indefinitely under Exponential growth; Constant
+// schedules are capped off Wait instead of MaxBackoff, since MaxBackoff
+// doesn't apply to them.
+func (cc *CommandConfig) newBackoff() retry.Backoff {
+	var b retry.Backoff
+	var maxElapsed time.Duration
+	switch cc.Backoff {
+	case Exponential:
+		b = exponentialBackoff(*cc.InitialBackoff, *cc.MaxBackoff, cc.Multiplier)
+		// jitter keeps concurrent retry loops from synchronizing on the same
+		// schedule and hammering the target at the same instant.
+		b = retry.WithJitter(*cc.InitialBackoff, b)
+		maxElapsed = *cc.MaxBackoff
+	case Constant:
+		fallthrough
+	default:
+		b = retry.NewConstant(*cc.Wait)
+		maxElapsed = *cc.Wait
+	}
+	b = retry.WithMaxRetries(uint64(cc.MaxRetries), b)
+	return retry.WithMaxDuration(maxElapsed*time.Duration(cc.MaxRetries+1), b)
+}
+
+// exponentialBackoff grows from initial by multiplier on each attempt,
+// capped at max.
+func exponentialBackoff(initial, max time.Duration, multiplier float64) retry.Backoff {
+	next := initial
+	return retry.BackoffFunc(func() (time.Duration, bool) {
+		cur := next
+		if cur > max {
+			cur = max
+		}
+		next = time.Duration(float64(next) * multiplier)
+		return cur, false
+	})
+}
+
+// Command is a convenience wrapper around an Executor for callers that just
+// want to run a command on the local host. It defaults to LocalExecutor;
+// use an Executor directly (e.g. KubectlExecExecutor, SSHExecutor) to run
+// the same Spec against a different transport.
 type Command struct {
-	raw  string
-	app  string
-	args []string
-	cfg  *CommandConfig
+	app      string
+	args     []string
+	cfg      *CommandConfig
+	executor Executor
 }
 
+// NewCommand tokenizes commandString using POSIX shell rules, so quoted
+// arguments, paths with spaces, and multi-space separators are preserved.
 func NewCommand(commandString string, cfg *CommandConfig) (*Command, error) {
 	cfg.validate()
 	if commandString == "" {
 		return nil, fmt.Errorf("cannot execute empty command")
 	}
 
-	parts := strings.Split(commandString, commandSeparator)
+	parts, err := shlex.Split(commandString)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizing command %q: %w", commandString, err)
+	}
 	if len(parts) < 2 {
 		return nil, fmt.Errorf("specified command %q is malformed, expected to be in format \"app args...\"", commandString)
 	}
 
 	return &Command{
-		raw:  commandString,
-		app:  parts[0],
-		args: parts[1:],
-		cfg:  cfg,
+		app:      parts[0],
+		args:     parts[1:],
+		cfg:      cfg,
+		executor: LocalExecutor{},
 	}, nil
 }
 
-func (c *Command) Execute() (*Result, error) {
-	if c.cfg == nil {
-		return execute(c)
-	}
-	if c.cfg.MaxRetries > 0 {
-		return executeWithRetries(c)
-	}
-	return executeWithTimeout(c)
-}
-
-func execute(c *Command) (*Result, error) {
-	cmd := exec.Command(c.app, c.args...)
-
-	stdout, err := cmd.Output()
-	if err != nil {
-		var exitError *exec.ExitError
-		if !errors.As(err, &exitError) {
-			return nil, fmt.Errorf("executing command %q: %w", c.raw, err)
-		}
-		return fromExitError(exitError), nil
+// NewCommandArgs builds a Command from an already-tokenized app and argument
+// list, for callers that don't want to round-trip through a shell string.
+func NewCommandArgs(app string, args []string, cfg *CommandConfig) (*Command, error) {
+	cfg.validate()
+	if app == "" {
+		return nil, fmt.Errorf("cannot execute empty command")
 	}
 
-	return &Result{
-		Stdout: string(stdout),
+	return &Command{
+		app:      app,
+		args:     args,
+		cfg:      cfg,
+		executor: LocalExecutor{},
 	}, nil
 }
 
-func executeWithTimeout(c *Command) (*Result, error) {
-	ch := make(chan struct {
-		err error
-		res *Result
-	})
-
-	ctx, cancel := context.WithTimeout(context.Background(), *c.cfg.Timeout)
-	defer cancel()
-
-	// TODO(cameissner): are these potentially leaky?
-	go func() {
-		res, err := execute(c)
-		ch <- struct {
-			err error
-			res *Result
-		}{err: err, res: res}
-	}()
+// WithExecutor swaps the transport c runs against, e.g. KubectlExecExecutor
+// or SSHExecutor in place of the default LocalExecutor.
+func (c *Command) WithExecutor(e Executor) *Command {
+	c.executor = e
+	return c
+}
 
-	select {
-	case r := <-ch:
-		return r.res, r.err
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	}
+// Execute runs the command using context.Background(). Prefer ExecuteContext
+// so that callers can cancel long-running retry loops.
+func (c *Command) Execute() (*Result, error) {
+	return c.ExecuteContext(context.Background())
 }
 
-// executeWithRetries attempts to emulate: https://github.com/Azure/AgentBaker/blob/master/parts/linux/cloud-init/artifacts/cse_helpers.sh#L133-L145
-func executeWithRetries(c *Command) (*Result, error) {
-	backoff := retry.WithMaxRetries(uint64(c.cfg.MaxRetries), retry.NewConstant(*c.cfg.Wait))
-	var res *Result
-	err := retry.Do(context.Background(), backoff, func(ctx context.Context) error {
-		var err error
-		res, err = executeWithTimeout(c)
-		if err != nil {
-			// retry if the command itself timed out
-			if errors.Is(err, context.DeadlineExceeded) {
-				return retry.RetryableError(err)
-			}
-			// don't retry if we weren't able to execute the command at all
-			return err
-		}
-		if err = res.AsError(); err != nil {
-			// blindly retry in the case where the command executed
-			// but ended up failing
-			return retry.RetryableError(err)
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return res, nil
+// ExecuteContext runs the command, honoring ctx for cancellation across the
+// timeout and retry layers alike.
+func (c *Command) ExecuteContext(ctx context.Context) (*Result, error) {
+	return c.executor.Run(ctx, &Spec{App: c.app, Args: c.args}, c.cfg)
 }
@@ -0,0 +1,156 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffCapsAtMax(t *testing.T) {
+	b := exponentialBackoff(10*time.Millisecond, 40*time.Millisecond, 2.0)
+
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}
+	for i, w := range want {
+		got, stop := b.Next()
+		if stop {
+			t.Fatalf("attempt %d: unexpected stop", i)
+		}
+		if got != w {
+			t.Errorf("attempt %d: got %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestNewBackoffConstantIgnoresMaxBackoff(t *testing.T) {
+	// MaxBackoff is deliberately tiny: if newBackoff still capped total
+	// elapsed time off MaxBackoff instead of Wait, a few milliseconds of
+	// real sleep between Next() calls would trip WithMaxDuration early.
+	cfg := &CommandConfig{
+		Wait:       toPtr(10 * time.Millisecond),
+		MaxRetries: 3,
+		MaxBackoff: toPtr(time.Millisecond),
+	}
+	cfg.validate()
+
+	backoff := cfg.newBackoff()
+	for i := 0; i < cfg.MaxRetries; i++ {
+		if i > 0 {
+			time.Sleep(3 * time.Millisecond)
+		}
+		if _, stop := backoff.Next(); stop {
+			t.Fatalf("attempt %d: schedule stopped early, want Constant's cap to be based on Wait, not MaxBackoff", i)
+		}
+	}
+}
+
+func TestShouldRetryCanRetryASuccessfulResult(t *testing.T) {
+	attempts := 0
+	cfg := &CommandConfig{
+		MaxRetries: 2,
+		Wait:       toPtr(time.Millisecond),
+		ShouldRetry: func(res *Result, err error) bool {
+			attempts++
+			return attempts < 3 // force every attempt but the last to retry
+		},
+	}
+	cfg.validate()
+
+	res, err := runWithRetries(context.Background(), cfg, func(ctx context.Context) (*Result, error) {
+		return &Result{ExitCode: 0}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res == nil {
+		t.Fatal("expected a result")
+	}
+	if attempts != 3 {
+		t.Errorf("got %d attempts, want 3 (ShouldRetry must be able to force a retry on a successful Result)", attempts)
+	}
+}
+
+func TestNewCommandTokenizesQuotedArgs(t *testing.T) {
+	cases := []struct {
+		name    string
+		command string
+		app     string
+		args    []string
+	}{
+		{
+			name:    "quoted argument with spaces",
+			command: `sh -c "echo hi && ls /var/lib"`,
+			app:     "sh",
+			args:    []string{"-c", "echo hi && ls /var/lib"},
+		},
+		{
+			name:    "multiple spaces between args",
+			command: "kubectl  exec  pod",
+			app:     "kubectl",
+			args:    []string{"exec", "pod"},
+		},
+		{
+			name:    "path with spaces",
+			command: `app '/path with spaces/bin'`,
+			app:     "app",
+			args:    []string{"/path with spaces/bin"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cmd, err := NewCommand(c.command, nil)
+			if err != nil {
+				t.Fatalf("NewCommand(%q): unexpected error: %v", c.command, err)
+			}
+			if cmd.app != c.app {
+				t.Errorf("app = %q, want %q", cmd.app, c.app)
+			}
+			if len(cmd.args) != len(c.args) {
+				t.Fatalf("args = %v, want %v", cmd.args, c.args)
+			}
+			for i, arg := range c.args {
+				if cmd.args[i] != arg {
+					t.Errorf("args[%d] = %q, want %q", i, cmd.args[i], arg)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalExecutorExecuteContextCancellation(t *testing.T) {
+	cmd, err := NewCommand("sleep 5", nil)
+	if err != nil {
+		t.Fatalf("NewCommand: unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = cmd.ExecuteContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ExecuteContext returned %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("ExecuteContext took %v to return after cancellation, want it to return promptly", elapsed)
+	}
+}
+
+func TestLocalExecutorStreamsOutput(t *testing.T) {
+	var stdout bytes.Buffer
+	cfg := &CommandConfig{Stdout: &stdout}
+	cmd, err := NewCommand("echo hello", cfg)
+	if err != nil {
+		t.Fatalf("NewCommand: unexpected error: %v", err)
+	}
+
+	res, err := cmd.Execute()
+	if err != nil {
+		t.Fatalf("Execute: unexpected error: %v", err)
+	}
+	if stdout.String() != res.Stdout {
+		t.Errorf("streamed stdout %q != buffered Result.Stdout %q", stdout.String(), res.Stdout)
+	}
+}
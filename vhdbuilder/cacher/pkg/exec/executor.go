@@ -0,0 +1,110 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sethvargo/go-retry"
+)
+
+// Spec describes a single command invocation, independent of where it runs.
+type Spec struct {
+	App  string
+	Args []string
+}
+
+// Executor runs a Spec against some transport (the local host, a pod via
+// kubectl exec, a remote host over SSH, ...) and returns its Result. Every
+// implementation honors cfg's timeout and retry settings via runOnce, and
+// streams output to cfg's Stdout/Stderr writers, so scenarios can swap
+// transports by construction rather than by branching in call sites.
+type Executor interface {
+	Run(ctx context.Context, spec *Spec, cfg *CommandConfig) (*Result, error)
+}
+
+// runOnceFunc performs a single, un-retried attempt at running a command.
+// Transports implement this and get timeout/retry behavior for free by
+// routing it through runOnce.
+type runOnceFunc func(ctx context.Context) (*Result, error)
+
+// ensureConfig returns cfg, defaulted and validated, substituting a fresh
+// CommandConfig when cfg is nil so callers never need to nil-check it
+// afterwards.
+func ensureConfig(cfg *CommandConfig) *CommandConfig {
+	if cfg == nil {
+		cfg = &CommandConfig{}
+	}
+	cfg.validate()
+	return cfg
+}
+
+// runOnce applies cfg's timeout and retry behavior around once, so each
+// Executor only has to implement the single-attempt case for its transport.
+// Callers must pass an already-ensureConfig'd cfg.
+func runOnce(ctx context.Context, cfg *CommandConfig, once runOnceFunc) (*Result, error) {
+	if cfg.MaxRetries > 0 {
+		return runWithRetries(ctx, cfg, once)
+	}
+	return runWithTimeout(ctx, cfg, once)
+}
+
+func runWithTimeout(ctx context.Context, cfg *CommandConfig, once runOnceFunc) (*Result, error) {
+	ch := make(chan struct {
+		err error
+		res *Result
+	}, 1)
+
+	ctx, cancel := context.WithTimeout(ctx, *cfg.Timeout)
+	defer cancel()
+
+	go func() {
+		res, err := once(ctx)
+		ch <- struct {
+			err error
+			res *Result
+		}{err: err, res: res}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.res, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runWithRetries attempts to emulate: https://github.com/Azure/AgentBaker/blob/master/parts/linux/cloud-init/artifacts/cse_helpers.sh#L133-L145
+func runWithRetries(ctx context.Context, cfg *CommandConfig, once runOnceFunc) (*Result, error) {
+	backoff := cfg.newBackoff()
+	var res *Result
+	err := retry.Do(ctx, backoff, func(ctx context.Context) error {
+		var err error
+		res, err = runWithTimeout(ctx, cfg, once)
+		if err != nil {
+			// retry if the command itself timed out; if the outer context
+			// was canceled/expired instead, retry.Do sees ctx done and
+			// returns ctx.Err() without making another attempt
+			if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+				return retry.RetryableError(err)
+			}
+			// don't retry if we weren't able to execute the command at all
+			return err
+		}
+		if cfg.ShouldRetry(res, nil) {
+			// retry.RetryableError treats a nil error as "done", so a
+			// predicate retrying an otherwise-successful Result still needs
+			// a non-nil error to actually trigger another attempt.
+			retryErr := res.AsError()
+			if retryErr == nil {
+				retryErr = fmt.Errorf("retrying command result per ShouldRetry (exit code %d)", res.ExitCode)
+			}
+			return retry.RetryableError(retryErr)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
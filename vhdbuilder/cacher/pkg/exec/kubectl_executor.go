@@ -0,0 +1,51 @@
+package exec
+
+import (
+	"context"
+)
+
+// KubectlExecExecutor runs a Spec inside a pod via `kubectl exec`, reusing
+// LocalExecutor's subprocess/streaming machinery for the kubectl invocation
+// itself, so scenarios can point bootstrap validation commands at a node's
+// debug pod instead of the local test host.
+type KubectlExecExecutor struct {
+	// Namespace and Pod identify the target, e.g. the debug pod mounting the
+	// node's filesystem.
+	Namespace string
+	Pod       string
+	// Container selects a specific container when Pod has more than one.
+	// Optional.
+	Container string
+}
+
+func (e KubectlExecExecutor) Run(ctx context.Context, spec *Spec, cfg *CommandConfig) (*Result, error) {
+	cfg = ensureConfig(cfg)
+	return runOnce(ctx, cfg, func(ctx context.Context) (*Result, error) {
+		// Dir/Env describe the remote command, not the local kubectl client
+		// process localRun spawns, so they're folded into the translated
+		// Spec below and stripped before reaching localRun.
+		return localRun(ctx, e.translate(spec, cfg), withoutRemoteOnlyConfig(cfg))
+	})
+}
+
+func (e KubectlExecExecutor) translate(spec *Spec, cfg *CommandConfig) *Spec {
+	args := []string{"exec", "-n", e.Namespace, e.Pod}
+	if e.Container != "" {
+		args = append(args, "-c", e.Container)
+	}
+	if cfg != nil && cfg.Stdin != nil {
+		// kubectl only attaches stdin to the remote command when -i is
+		// passed, so without this cfg.Stdin would silently never reach the
+		// pod even though localRun below still wires it up for the local
+		// kubectl client process.
+		args = append(args, "-i")
+	}
+	args = append(args, "--")
+	if hasRemoteOnlyConfig(cfg) {
+		args = append(args, "sh", "-c", remoteCommandLine(spec, cfg))
+	} else {
+		args = append(args, spec.App)
+		args = append(args, spec.Args...)
+	}
+	return &Spec{App: "kubectl", Args: args}
+}
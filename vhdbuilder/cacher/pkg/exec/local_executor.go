@@ -0,0 +1,96 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// LocalExecutor runs commands as subprocesses of the current process, via
+// os/exec. This is the default transport used by Command.
+type LocalExecutor struct{}
+
+func (LocalExecutor) Run(ctx context.Context, spec *Spec, cfg *CommandConfig) (*Result, error) {
+	cfg = ensureConfig(cfg)
+	return runOnce(ctx, cfg, func(ctx context.Context) (*Result, error) {
+		return localRun(ctx, spec, cfg)
+	})
+}
+
+// localRun streams stdout/stderr to the configured writers as the subprocess
+// produces them, while still buffering the full output for Result.
+func localRun(ctx context.Context, spec *Spec, cfg *CommandConfig) (*Result, error) {
+	cmd := exec.CommandContext(ctx, spec.App, spec.Args...)
+	if cfg != nil {
+		cmd.Stdin = cfg.Stdin
+		cmd.Env = cfg.Env
+		cmd.Dir = cfg.Dir
+	}
+
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("attaching stdout pipe for command %q: %w", spec.App, err)
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("attaching stderr pipe for command %q: %w", spec.App, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("executing command %q: %w", spec.App, err)
+	}
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	stdoutWriter, stderrWriter := streamWriters(cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(stdoutWriter, io.TeeReader(stdoutPipe, &stdoutBuf))
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(stderrWriter, io.TeeReader(stderrPipe, &stderrBuf))
+	}()
+	// wait for both copy goroutines before cmd.Wait, since cmd.Wait closes
+	// the pipes out from under them otherwise.
+	wg.Wait()
+
+	if err := cmd.Wait(); err != nil {
+		var exitError *exec.ExitError
+		if !errors.As(err, &exitError) {
+			return nil, fmt.Errorf("executing command %q: %w", spec.App, err)
+		}
+		return &Result{
+			Stdout:   stdoutBuf.String(),
+			Stderr:   stderrBuf.String(),
+			ExitCode: exitError.ExitCode(),
+		}, nil
+	}
+
+	return &Result{
+		Stdout: stdoutBuf.String(),
+		Stderr: stderrBuf.String(),
+	}, nil
+}
+
+// streamWriters returns the writers copy goroutines should tee output into,
+// falling back to io.Discard so localRun never has to nil-check them.
+func streamWriters(cfg *CommandConfig) (stdout, stderr io.Writer) {
+	stdout, stderr = io.Discard, io.Discard
+	if cfg == nil {
+		return stdout, stderr
+	}
+	if cfg.Stdout != nil {
+		stdout = cfg.Stdout
+	}
+	if cfg.Stderr != nil {
+		stderr = cfg.Stderr
+	}
+	return stdout, stderr
+}
@@ -0,0 +1,50 @@
+package exec
+
+import "strings"
+
+// remoteCommandLine renders spec, plus cfg's Dir/Env, as a single POSIX
+// shell command line. Transports that hand one command string to a remote
+// shell (SSH, `kubectl exec ... -- sh -c ...`) use this instead of exec'ing
+// an argv directly, since that's the only portable way to apply Dir/Env on
+// the remote side.
+func remoteCommandLine(spec *Spec, cfg *CommandConfig) string {
+	var parts []string
+	if cfg != nil && cfg.Dir != "" {
+		parts = append(parts, "cd", quoteArg(cfg.Dir), "&&")
+	}
+	if cfg != nil && len(cfg.Env) > 0 {
+		parts = append(parts, "env")
+		for _, kv := range cfg.Env {
+			parts = append(parts, quoteArg(kv))
+		}
+	}
+	parts = append(parts, quoteArg(spec.App))
+	for _, arg := range spec.Args {
+		parts = append(parts, quoteArg(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteArg(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// hasRemoteOnlyConfig reports whether cfg carries settings that only make
+// sense applied on the remote side of a transport (as opposed to the local
+// process that dials out to it, e.g. the local `kubectl` client).
+func hasRemoteOnlyConfig(cfg *CommandConfig) bool {
+	return cfg != nil && (cfg.Dir != "" || len(cfg.Env) > 0)
+}
+
+// withoutRemoteOnlyConfig returns a copy of cfg with Dir/Env cleared, for
+// passing to a local subprocess (e.g. the kubectl client) that shouldn't
+// have the remote command's Dir/Env silently applied to it instead.
+func withoutRemoteOnlyConfig(cfg *CommandConfig) *CommandConfig {
+	if cfg == nil {
+		return nil
+	}
+	clone := *cfg
+	clone.Dir = ""
+	clone.Env = nil
+	return &clone
+}
@@ -0,0 +1,122 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHExecutor runs commands over a single persistent SSH connection,
+// similar to the Communicator pattern used by packer/terraform
+// provisioners: one dial up front, then one session (and one shell
+// invocation) per command.
+type SSHExecutor struct {
+	client *ssh.Client
+}
+
+// NewSSHExecutor dials addr (host:port) and keeps the connection open for
+// subsequent Run calls.
+func NewSSHExecutor(addr string, config *ssh.ClientConfig) (*SSHExecutor, error) {
+	client, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("dialing ssh host %q: %w", addr, err)
+	}
+	return &SSHExecutor{client: client}, nil
+}
+
+// Close closes the underlying SSH connection.
+func (e *SSHExecutor) Close() error {
+	return e.client.Close()
+}
+
+func (e *SSHExecutor) Run(ctx context.Context, spec *Spec, cfg *CommandConfig) (*Result, error) {
+	cfg = ensureConfig(cfg)
+	return runOnce(ctx, cfg, func(ctx context.Context) (*Result, error) {
+		return e.sessionRun(ctx, spec, cfg)
+	})
+}
+
+func (e *SSHExecutor) sessionRun(ctx context.Context, spec *Spec, cfg *CommandConfig) (*Result, error) {
+	session, err := e.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("opening ssh session: %w", err)
+	}
+	defer session.Close()
+
+	if cfg != nil {
+		session.Stdin = cfg.Stdin
+	}
+
+	stdoutPipe, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("attaching stdout pipe for command %q: %w", spec.App, err)
+	}
+	stderrPipe, err := session.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("attaching stderr pipe for command %q: %w", spec.App, err)
+	}
+
+	if err := session.Start(remoteCommandLine(spec, cfg)); err != nil {
+		return nil, fmt.Errorf("executing command %q over ssh: %w", spec.App, err)
+	}
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	stdoutWriter, stderrWriter := streamWriters(cfg)
+
+	// Start the pipe copies and session.Wait concurrently, up front, so a
+	// canceled ctx can race them instead of waiting for the remote command
+	// to exit on its own first.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(stdoutWriter, io.TeeReader(stdoutPipe, &stdoutBuf))
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(stderrWriter, io.TeeReader(stderrPipe, &stderrBuf))
+	}()
+	copyDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(copyDone)
+	}()
+
+	waitDone := make(chan error, 1)
+	go func() { waitDone <- session.Wait() }()
+
+	select {
+	case <-ctx.Done():
+		// Closing the session forces the remote command to exit, which in
+		// turn closes the stdout/stderr pipes and unblocks the copy
+		// goroutines above, so we join them before returning rather than
+		// leaking them.
+		_ = session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-copyDone
+		<-waitDone
+		return nil, ctx.Err()
+	case err := <-waitDone:
+		<-copyDone
+		if err != nil {
+			var exitErr *ssh.ExitError
+			if !errors.As(err, &exitErr) {
+				return nil, fmt.Errorf("executing command %q over ssh: %w", spec.App, err)
+			}
+			return &Result{
+				Stdout:   stdoutBuf.String(),
+				Stderr:   stderrBuf.String(),
+				ExitCode: exitErr.ExitStatus(),
+			}, nil
+		}
+		return &Result{
+			Stdout: stdoutBuf.String(),
+			Stderr: stderrBuf.String(),
+		}, nil
+	}
+}